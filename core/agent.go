@@ -0,0 +1,204 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// agentRequest is one request in the agent's line protocol: a JSON object
+// per connection write, decoded with json.Decoder so a client can send
+// several requests over one connection.
+type agentRequest struct {
+	Op       string    `json:"op"`
+	Id       string    `json:"id,omitempty"`
+	Label    string    `json:"label,omitempty"`
+	Account  string    `json:"account,omitempty"`
+	Query    string    `json:"query,omitempty"`
+	Password *Password `json:"password,omitempty"`
+}
+
+type agentResponse struct {
+	Error     string      `json:"error,omitempty"`
+	Id        string      `json:"id,omitempty"`
+	Updated   bool        `json:"updated,omitempty"`
+	Passwords []*Password `json:"passwords,omitempty"`
+}
+
+// Agent serves a single already-unlocked Box's add/find operations over a
+// Unix-domain socket, so the other onepw subcommands can reuse it instead
+// of asking for the master password again every time. The box's data key
+// is kept mlock'd in memory and zeroed once IdleTimeout passes without a
+// request, at which point the Agent stops serving.
+type Agent struct {
+	box         *Box
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	lastUsed time.Time
+}
+
+// NewAgent creates an Agent serving box, which must already be unlocked
+// (Init must have succeeded).
+func NewAgent(box *Box, idleTimeout time.Duration) *Agent {
+	return &Agent{box: box, idleTimeout: idleTimeout, lastUsed: time.Now()}
+}
+
+// ListenAndServe listens on socketPath and serves requests until the idle
+// timeout zeroes the box's key or the listener errors.
+func (a *Agent) ListenAndServe(socketPath string) error {
+	if err := os.RemoveAll(socketPath); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(socketPath)
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	if err := mlock(a.box.dataKey); err != nil {
+		return fmt.Errorf("mlock: %v", err)
+	}
+	defer munlock(a.box.dataKey)
+
+	go a.watchIdle(listener)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go a.handle(conn)
+	}
+}
+
+// watchIdle closes listener once IdleTimeout passes without a request,
+// after zeroing the box's data key so it doesn't linger in memory.
+func (a *Agent) watchIdle(listener net.Listener) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.mu.Lock()
+		idle := time.Since(a.lastUsed)
+		a.mu.Unlock()
+		if idle >= a.idleTimeout {
+			a.mu.Lock()
+			a.box.Zero()
+			a.mu.Unlock()
+			listener.Close()
+			return
+		}
+	}
+}
+
+func (a *Agent) touch() {
+	a.mu.Lock()
+	a.lastUsed = time.Now()
+	a.mu.Unlock()
+}
+
+func (a *Agent) handle(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req agentRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		a.touch()
+		if err := enc.Encode(a.dispatch(&req)); err != nil {
+			return
+		}
+	}
+}
+
+func (a *Agent) dispatch(req *agentRequest) agentResponse {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.box.dataKey == nil {
+		return agentResponse{Error: "agent has timed out, unlock the box again"}
+	}
+
+	switch req.Op {
+	case "add":
+		id, updated, err := a.box.Add(req.Password)
+		if err != nil {
+			return agentResponse{Error: err.Error()}
+		}
+		return agentResponse{Id: id, Updated: updated}
+	case "find":
+		passwords, err := a.box.Find(req.Id, req.Label, req.Account, req.Query)
+		if err != nil {
+			return agentResponse{Error: err.Error()}
+		}
+		return agentResponse{Passwords: passwords}
+	default:
+		return agentResponse{Error: fmt.Sprintf("unknown op %q", req.Op)}
+	}
+}
+
+// AgentClient talks to a running Agent over its Unix-domain socket, so a
+// caller can reuse an already-unlocked box instead of prompting for the
+// master password again.
+type AgentClient struct {
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+}
+
+const agentDialTimeout = 500 * time.Millisecond
+
+// DialAgent connects to the agent listening on socketPath.
+func DialAgent(socketPath string) (*AgentClient, error) {
+	conn, err := net.DialTimeout("unix", socketPath, agentDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentClient{conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}, nil
+}
+
+func (c *AgentClient) call(req agentRequest) (agentResponse, error) {
+	if err := c.enc.Encode(req); err != nil {
+		return agentResponse{}, err
+	}
+	var resp agentResponse
+	if err := c.dec.Decode(&resp); err != nil {
+		return agentResponse{}, err
+	}
+	if resp.Error != "" {
+		return agentResponse{}, fmt.Errorf("%s", resp.Error)
+	}
+	return resp, nil
+}
+
+// Add behaves like Box.Add, via the agent.
+func (c *AgentClient) Add(pw *Password) (string, bool, error) {
+	resp, err := c.call(agentRequest{Op: "add", Password: pw})
+	if err != nil {
+		return "", false, err
+	}
+	return resp.Id, resp.Updated, nil
+}
+
+// Find behaves like Box.Find, via the agent.
+func (c *AgentClient) Find(id, label, account, query string) ([]*Password, error) {
+	resp, err := c.call(agentRequest{Op: "find", Id: id, Label: label, Account: account, Query: query})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Passwords, nil
+}
+
+// Close closes the connection to the agent.
+func (c *AgentClient) Close() error {
+	return c.conn.Close()
+}
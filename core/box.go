@@ -0,0 +1,399 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyFile is one master-password entry that can unlock a Box's data key.
+// A Box can hold several of these, so several people (or several devices
+// for the same person) can each keep their own master password while
+// sharing a single set of encrypted entries.
+type KeyFile struct {
+	Id         string    `json:"id"`
+	Username   string    `json:"username"`
+	Hostname   string    `json:"hostname"`
+	Created    time.Time `json:"created"`
+	KDFParams  KDFParams `json:"kdfParams"`
+	WrappedKey []byte    `json:"wrappedKey"` // the box's data key, encrypted with this key's derived key
+}
+
+// onDiskBox is the JSON shape persisted by a Repository. Passwords is the
+// data key's entries, encrypted as a single blob so the key management
+// metadata in Keys can stay in the clear.
+type onDiskBox struct {
+	Keys      []*KeyFile `json:"keys"`
+	Passwords []byte     `json:"passwords,omitempty"`
+}
+
+// Box is a password box: a set of encrypted Password entries plus the
+// KeyFiles that can unlock them.
+type Box struct {
+	repo Repository
+
+	keys      []*KeyFile
+	passwords []*Password
+
+	dataKey     []byte // decrypted data-encryption key, set once Init succeeds
+	activeKeyId string // id of the KeyFile used to unlock this session
+}
+
+// NewBox creates a Box backed by repo. Init must be called with a master
+// password before the box can be used.
+func NewBox(repo Repository) *Box {
+	return &Box{repo: repo}
+}
+
+// Init unlocks the box with masterPassword, or creates a brand new box
+// (with a single key) if the repository is empty.
+//
+// The master password never touches the stored entries directly: it only
+// unwraps the box's data-encryption key, which is what actually encrypts
+// the passwords. That indirection is what lets a box have several master
+// passwords (see AddKey/RemoveKey/RotateKey) without re-encrypting every
+// entry whenever a key is added, removed or rotated.
+func (box *Box) Init(masterPassword string) error {
+	raw, err := box.repo.Load()
+	if err != nil {
+		return err
+	}
+	if len(raw) == 0 {
+		return box.initFresh(masterPassword)
+	}
+
+	var disk onDiskBox
+	if err := json.Unmarshal(raw, &disk); err != nil {
+		return err
+	}
+	if len(disk.Keys) == 0 {
+		return fmt.Errorf("box has no keys")
+	}
+
+	for _, key := range disk.Keys {
+		dataKey, err := unwrapDataKey(key, masterPassword)
+		if err != nil {
+			continue
+		}
+		box.keys = disk.Keys
+		box.dataKey = dataKey
+		box.activeKeyId = key.Id
+		return box.decryptPasswords(disk.Passwords)
+	}
+	return fmt.Errorf("invalid master password")
+}
+
+func (box *Box) initFresh(masterPassword string) error {
+	dataKey := make([]byte, 32)
+	if err := randomBytes(dataKey); err != nil {
+		return err
+	}
+	key, err := newKeyFile("1", "", "", masterPassword, dataKey)
+	if err != nil {
+		return err
+	}
+	box.keys = []*KeyFile{key}
+	box.dataKey = dataKey
+	box.activeKeyId = key.Id
+	box.passwords = nil
+	return box.save()
+}
+
+func unwrapDataKey(key *KeyFile, password string) ([]byte, error) {
+	wrapKey, err := deriveKey(password, key.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(wrapKey, key.WrappedKey)
+}
+
+func newKeyFile(id, username, hostname, password string, dataKey []byte) (*KeyFile, error) {
+	params, err := defaultKDFParams()
+	if err != nil {
+		return nil, err
+	}
+	wrapKey, err := deriveKey(password, params)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := encrypt(wrapKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyFile{
+		Id:         id,
+		Username:   username,
+		Hostname:   hostname,
+		Created:    time.Now(),
+		KDFParams:  params,
+		WrappedKey: wrapped,
+	}, nil
+}
+
+func (box *Box) decryptPasswords(blob []byte) error {
+	if len(blob) == 0 {
+		box.passwords = nil
+		return nil
+	}
+	plain, err := decrypt(box.dataKey, blob)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plain, &box.passwords)
+}
+
+func (box *Box) save() error {
+	plain, err := json.Marshal(box.passwords)
+	if err != nil {
+		return err
+	}
+	blob, err := encrypt(box.dataKey, plain)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(onDiskBox{Keys: box.keys, Passwords: blob})
+	if err != nil {
+		return err
+	}
+	if err := box.repo.Lock(); err != nil {
+		return err
+	}
+	defer box.repo.Unlock()
+	return box.repo.Save(raw)
+}
+
+func (box *Box) nextId() string {
+	max := 0
+	for _, pw := range box.passwords {
+		if n, err := strconv.Atoi(pw.Id); err == nil && n > max {
+			max = n
+		}
+	}
+	return strconv.Itoa(max + 1)
+}
+
+// requireUnlocked returns an error if Init hasn't succeeded (or Zero has
+// since reversed it), so callers that mutate the box fail with a clear
+// message instead of falling through to encrypt() with a nil data key.
+func (box *Box) requireUnlocked() error {
+	if !box.Unlocked() {
+		return fmt.Errorf("box is not unlocked, pass --master")
+	}
+	return nil
+}
+
+// Add inserts pw as a new entry, or updates the existing entry with the
+// same Label and Account if one already exists. It returns the entry's id
+// and whether it updated rather than inserted.
+func (box *Box) Add(pw *Password) (string, bool, error) {
+	if err := box.requireUnlocked(); err != nil {
+		return "", false, err
+	}
+	now := time.Now()
+	for _, existing := range box.passwords {
+		if existing.Label == pw.Label && existing.Account == pw.Account {
+			existing.Site = pw.Site
+			existing.Note = pw.Note
+			existing.PlainPassword = pw.PlainPassword
+			existing.UpdatedAt = now
+			if err := box.save(); err != nil {
+				return "", false, err
+			}
+			return existing.Id, true, nil
+		}
+	}
+	pw.Id = box.nextId()
+	pw.CreatedAt = now
+	pw.UpdatedAt = now
+	box.passwords = append(box.passwords, pw)
+	if err := box.save(); err != nil {
+		return "", false, err
+	}
+	return pw.Id, false, nil
+}
+
+// Remove deletes the entry with the given id and returns the removed ids.
+// If all is false only the first matching entry is removed; if nothing
+// matches, it is a no-op.
+func (box *Box) Remove(id string, all bool) ([]string, error) {
+	return box.removeWhere(func(pw *Password) bool { return pw.Id == id }, all)
+}
+
+// RemoveByAccount deletes the first entry matching label and/or account, or
+// every matching entry if all is true.
+func (box *Box) RemoveByAccount(label, account string, all bool) ([]string, error) {
+	return box.removeWhere(func(pw *Password) bool {
+		return (label == "" || pw.Label == label) && (account == "" || pw.Account == account)
+	}, all)
+}
+
+// Clear deletes every entry in the box.
+func (box *Box) Clear() ([]string, error) {
+	return box.removeWhere(func(pw *Password) bool { return true }, true)
+}
+
+func (box *Box) removeWhere(match func(*Password) bool, all bool) ([]string, error) {
+	if err := box.requireUnlocked(); err != nil {
+		return nil, err
+	}
+	var (
+		removed []string
+		kept    []*Password
+		matched bool
+	)
+	for _, pw := range box.passwords {
+		if match(pw) && (all || !matched) {
+			matched = true
+			removed = append(removed, pw.Id)
+			continue
+		}
+		kept = append(kept, pw)
+	}
+	box.passwords = kept
+	if len(removed) > 0 {
+		if err := box.save(); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// Find returns every entry matching all of the given filters. id, label and
+// account match exactly and are skipped when empty; query fuzzy-matches
+// (case-insensitive substring) against label, account, site and note, and
+// is skipped when empty.
+func (box *Box) Find(id, label, account, query string) ([]*Password, error) {
+	if err := box.requireUnlocked(); err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(query)
+	var out []*Password
+	for _, pw := range box.passwords {
+		if id != "" && pw.Id != id {
+			continue
+		}
+		if label != "" && pw.Label != label {
+			continue
+		}
+		if account != "" && pw.Account != account {
+			continue
+		}
+		if query != "" && !matchesQuery(pw, query) {
+			continue
+		}
+		out = append(out, pw)
+	}
+	return out, nil
+}
+
+func matchesQuery(pw *Password, query string) bool {
+	for _, field := range []string{pw.Label, pw.Account, pw.Site, pw.Note} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+//----------------
+// key management
+//----------------
+
+// ListKeys returns every KeyFile unlocking this box.
+func (box *Box) ListKeys() []*KeyFile {
+	return box.keys
+}
+
+// AddKey wraps the box's data key with a new password, so it can unlock
+// the box independently of the key used by Init. It returns the new key's
+// id.
+func (box *Box) AddKey(username, hostname, password string) (string, error) {
+	if err := box.requireUnlocked(); err != nil {
+		return "", err
+	}
+	id := box.nextKeyId()
+	key, err := newKeyFile(id, username, hostname, password, box.dataKey)
+	if err != nil {
+		return "", err
+	}
+	box.keys = append(box.keys, key)
+	if err := box.save(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// RemoveKey revokes the key with the given id. The last remaining key
+// cannot be removed, since that would make the box permanently unreadable.
+func (box *Box) RemoveKey(id string) error {
+	if err := box.requireUnlocked(); err != nil {
+		return err
+	}
+	if len(box.keys) <= 1 {
+		return fmt.Errorf("cannot remove the last key")
+	}
+	kept := box.keys[:0]
+	found := false
+	for _, key := range box.keys {
+		if key.Id == id {
+			found = true
+			continue
+		}
+		kept = append(kept, key)
+	}
+	if !found {
+		return fmt.Errorf("key %s not found", id)
+	}
+	box.keys = kept
+	return box.save()
+}
+
+// RotateKey replaces the password protecting the key with the given id,
+// without touching any other key or re-encrypting any entry.
+func (box *Box) RotateKey(id, newPassword string) error {
+	if err := box.requireUnlocked(); err != nil {
+		return err
+	}
+	for i, key := range box.keys {
+		if key.Id != id {
+			continue
+		}
+		rotated, err := newKeyFile(key.Id, key.Username, key.Hostname, newPassword, box.dataKey)
+		if err != nil {
+			return err
+		}
+		box.keys[i] = rotated
+		return box.save()
+	}
+	return fmt.Errorf("key %s not found", id)
+}
+
+// Unlocked reports whether Init has succeeded (and Zero hasn't since
+// reversed it), i.e. whether the box's data key is available to use.
+func (box *Box) Unlocked() bool {
+	return box.dataKey != nil
+}
+
+// Zero overwrites the box's decrypted data key and drops its in-memory
+// passwords, so a process that keeps a Box around (such as the agent,
+// after its idle timeout) doesn't go on holding the secrets in the clear.
+// The box must be re-unlocked with Init before it can be used again.
+func (box *Box) Zero() {
+	for i := range box.dataKey {
+		box.dataKey[i] = 0
+	}
+	box.dataKey = nil
+	box.passwords = nil
+}
+
+func (box *Box) nextKeyId() string {
+	max := 0
+	for _, key := range box.keys {
+		if n, err := strconv.Atoi(key.Id); err == nil && n > max {
+			max = n
+		}
+	}
+	return strconv.Itoa(max + 1)
+}
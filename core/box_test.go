@@ -0,0 +1,126 @@
+package core
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestBox(t *testing.T) *Box {
+	t.Helper()
+	return NewBox(NewFileRepository(newTestBoxFile(t)))
+}
+
+func newTestBoxFile(t *testing.T) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "password.data")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	return filename
+}
+
+func TestInitCreatesFreshBoxAtAPathThatDoesNotExistYet(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "does-not-exist-yet.data")
+
+	box := NewBox(NewFileRepository(filename))
+	if err := box.Init("master-password"); err != nil {
+		t.Fatalf("Init against a nonexistent file: %v", err)
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatalf("Init should have created %s: %v", filename, err)
+	}
+}
+
+func TestBoxMutationsRequireUnlock(t *testing.T) {
+	box := newTestBox(t)
+
+	if _, _, err := box.Add(&Password{Label: "email", PlainPassword: "secret"}); err == nil {
+		t.Fatal("Add before Init should fail")
+	}
+	if _, err := box.AddKey("", "", "new-password"); err == nil {
+		t.Fatal("AddKey before Init should fail")
+	}
+	if err := box.RemoveKey("1"); err == nil {
+		t.Fatal("RemoveKey before Init should fail")
+	}
+	if err := box.RotateKey("1", "new-password"); err == nil {
+		t.Fatal("RotateKey before Init should fail")
+	}
+	if _, err := box.Remove("1", false); err == nil {
+		t.Fatal("Remove before Init should fail")
+	}
+	if _, err := box.RemoveByAccount("email", "", false); err == nil {
+		t.Fatal("RemoveByAccount before Init should fail")
+	}
+	if _, err := box.Clear(); err == nil {
+		t.Fatal("Clear before Init should fail")
+	}
+	if _, err := box.Find("", "", "", ""); err == nil {
+		t.Fatal("Find before Init should fail")
+	}
+	if err := box.Export(io.Discard, "json"); err == nil {
+		t.Fatal("Export before Init should fail")
+	}
+
+	if err := box.Init("master-password"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if _, _, err := box.Add(&Password{Label: "email", PlainPassword: "secret"}); err != nil {
+		t.Fatalf("Add after Init: %v", err)
+	}
+
+	box.Zero()
+	if _, _, err := box.Add(&Password{Label: "email", PlainPassword: "secret"}); err == nil {
+		t.Fatal("Add after Zero should fail")
+	}
+}
+
+func TestAddKeyLetsNewPasswordUnlockTheSameBox(t *testing.T) {
+	filename := newTestBoxFile(t)
+
+	box := NewBox(NewFileRepository(filename))
+	if err := box.Init("master-password"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if _, _, err := box.Add(&Password{Label: "email", PlainPassword: "secret"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := box.AddKey("bob", "bobs-laptop", "bobs-password"); err != nil {
+		t.Fatalf("AddKey: %v", err)
+	}
+
+	other := NewBox(NewFileRepository(filename))
+	if err := other.Init("bobs-password"); err != nil {
+		t.Fatalf("Init with the added key's password: %v", err)
+	}
+	matches, err := other.Find("", "email", "", "")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(matches) != 1 || matches[0].PlainPassword != "secret" {
+		t.Fatalf("Find via added key = %+v, want the entry added under the original key", matches)
+	}
+}
+
+func TestRotateKeyReplacesThePasswordForThatKeyOnly(t *testing.T) {
+	filename := newTestBoxFile(t)
+
+	box := NewBox(NewFileRepository(filename))
+	if err := box.Init("master-password"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := box.RotateKey("1", "rotated-password"); err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+
+	if err := NewBox(NewFileRepository(filename)).Init("master-password"); err == nil {
+		t.Fatal("Init with the old password should fail after RotateKey")
+	}
+	if err := NewBox(NewFileRepository(filename)).Init("rotated-password"); err != nil {
+		t.Fatalf("Init with the rotated password: %v", err)
+	}
+}
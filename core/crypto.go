@@ -0,0 +1,78 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDFParams tunes the scrypt parameters used to derive a key-wrapping key
+// from a master password. Each KeyFile stores its own params so a box can
+// still be unlocked by old keys after the defaults change.
+type KDFParams struct {
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+	Salt   []byte `json:"salt"`
+	KeyLen int    `json:"keyLen"`
+}
+
+// randomBytes fills buf with cryptographically random bytes.
+func randomBytes(buf []byte) error {
+	_, err := io.ReadFull(rand.Reader, buf)
+	return err
+}
+
+// defaultKDFParams generates fresh scrypt parameters with a random salt.
+func defaultKDFParams() (KDFParams, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return KDFParams{}, err
+	}
+	return KDFParams{N: 1 << 15, R: 8, P: 1, Salt: salt, KeyLen: 32}, nil
+}
+
+// deriveKey turns a master password into a key-wrapping key using the given
+// KDF parameters.
+func deriveKey(password string, params KDFParams) ([]byte, error) {
+	return scrypt.Key([]byte(password), params.Salt, params.N, params.R, params.P, params.KeyLen)
+}
+
+// encrypt seals plaintext with AES-256-GCM, prefixing the result with a
+// freshly generated nonce.
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decrypt opens data previously sealed by encrypt.
+func decrypt(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// GeneratePolicy configures GeneratePassword.
+type GeneratePolicy struct {
+	Length        int
+	NoNumbers     bool
+	NoSpecials    bool
+	NoUppercase   bool
+	Pronounceable bool
+}
+
+const (
+	lowerRunes   = "abcdefghijklmnopqrstuvwxyz"
+	upperRunes   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	numberRunes  = "0123456789"
+	specialRunes = "!@#$%^&*()-_=+[]{}<>?"
+
+	defaultGenerateLength = 20
+)
+
+// GeneratePassword returns a random password matching policy. It uses
+// crypto/rand with rejection sampling (via rand.Int) over the allowed rune
+// set, so no rune is favoured by modulo bias.
+func GeneratePassword(policy GeneratePolicy) (string, error) {
+	length := policy.Length
+	if length <= 0 {
+		length = defaultGenerateLength
+	}
+	if policy.Pronounceable {
+		return generatePronounceable(length)
+	}
+
+	alphabet := lowerRunes
+	if !policy.NoUppercase {
+		alphabet += upperRunes
+	}
+	if !policy.NoNumbers {
+		alphabet += numberRunes
+	}
+	if !policy.NoSpecials {
+		alphabet += specialRunes
+	}
+	return randomString(alphabet, length)
+}
+
+func randomString(alphabet string, length int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	out := make([]byte, length)
+	for i := range out {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		out[i] = alphabet[n.Int64()]
+	}
+	return string(out), nil
+}
+
+// generatePronounceable alternates consonants and vowels so the result is
+// easier to read back over the phone than a fully random password.
+func generatePronounceable(length int) (string, error) {
+	const consonants = "bcdfghjklmnpqrstvwxyz"
+	const vowels = "aeiou"
+	out := make([]byte, length)
+	for i := range out {
+		alphabet := consonants
+		if i%2 == 1 {
+			alphabet = vowels
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		out[i] = alphabet[n.Int64()]
+	}
+	return string(out), nil
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// knownCSVHeaders maps the column headings used by common password
+// managers' CSV exports (LastPass, 1Password, Bitwarden, KeePass, ...)
+// onto the Password field they correspond to.
+var knownCSVHeaders = map[string]string{
+	"name":     "label",
+	"title":    "label",
+	"label":    "label",
+	"url":      "site",
+	"website":  "site",
+	"site":     "site",
+	"username": "account",
+	"login":    "account",
+	"account":  "account",
+	"password": "password",
+	"note":     "note",
+	"notes":    "note",
+}
+
+// ImportResult summarizes how Import reconciled incoming entries with the
+// entries already in the box.
+type ImportResult struct {
+	Added   int
+	Updated int
+	Skipped int
+}
+
+// Import reads entries in the given format from r and adds them to the
+// box, deduplicating by (label, account) the same way Add does.
+func (box *Box) Import(r io.Reader, format string) (ImportResult, error) {
+	var (
+		result  ImportResult
+		entries []*Password
+		err     error
+	)
+	switch format {
+	case "csv":
+		entries, err = parseCSVPasswords(r)
+	case "json":
+		entries, err = parseJSONPasswords(r)
+	case "1pux", "kdbx":
+		// See the matching comment on Export: neither format is
+		// implemented, deliberately, for the same reasons, and the same
+		// sign-off is needed before treating this as done rather than a
+		// quiet scope cut.
+		return result, fmt.Errorf("%s import is not implemented yet", format)
+	default:
+		return result, fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	for _, entry := range entries {
+		if entry.Label == "" && entry.Account == "" {
+			result.Skipped++
+			continue
+		}
+		_, updated, err := box.Add(entry)
+		if err != nil {
+			return result, err
+		}
+		if updated {
+			result.Updated++
+		} else {
+			result.Added++
+		}
+	}
+	return result, nil
+}
+
+func parseCSVPasswords(r io.Reader) ([]*Password, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	fields := make([]string, len(rows[0]))
+	for i, header := range rows[0] {
+		fields[i] = knownCSVHeaders[strings.ToLower(strings.TrimSpace(header))]
+	}
+
+	var out []*Password
+	for _, row := range rows[1:] {
+		pw := &Password{}
+		for i, value := range row {
+			if i >= len(fields) {
+				break
+			}
+			switch fields[i] {
+			case "label":
+				pw.Label = value
+			case "account":
+				pw.Account = value
+			case "site":
+				pw.Site = value
+			case "note":
+				pw.Note = value
+			case "password":
+				pw.PlainPassword = value
+			}
+		}
+		out = append(out, pw)
+	}
+	return out, nil
+}
+
+// parseJSONPasswords decodes a JSON array of objects, mapping each object's
+// keys onto Password fields through knownCSVHeaders the same way the CSV
+// path maps column headings: real-world exporters (LastPass, 1Password,
+// Bitwarden, KeePass, ...) use their own lowercase key names, not Go's
+// PascalCase Password field names.
+func parseJSONPasswords(r io.Reader) ([]*Password, error) {
+	var rows []map[string]string
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, err
+	}
+
+	var out []*Password
+	for _, row := range rows {
+		pw := &Password{}
+		for key, value := range row {
+			switch knownCSVHeaders[strings.ToLower(strings.TrimSpace(key))] {
+			case "label":
+				pw.Label = value
+			case "account":
+				pw.Account = value
+			case "site":
+				pw.Site = value
+			case "note":
+				pw.Note = value
+			case "password":
+				pw.PlainPassword = value
+			}
+		}
+		out = append(out, pw)
+	}
+	return out, nil
+}
+
+// Export streams every entry in the box to w in the given format.
+//
+// 1pux and kdbx are accepted (so --format=kdbx fails with a clear error
+// instead of "unknown export format") but not implemented: 1pux is
+// 1Password's own proprietary container, and a correct kdbx writer needs
+// a real KDBX4 reader on hand to validate the output against, which this
+// environment doesn't have. Shipping a hand-rolled encrypted format with
+// no way to confirm it actually opens in KeePass would be worse than the
+// plaintext formats it's meant to replace.
+//
+// This cuts roughly half of what chunk0-5 originally asked for (1pux and
+// kdbx interop), so it should not be merged as a quiet scope reduction:
+// whoever owns that request needs to explicitly sign off on csv/json-only
+// import/export, or ask for 1pux/kdbx to be picked back up as follow-on
+// work, before this is considered done.
+func (box *Box) Export(w io.Writer, format string) error {
+	if err := box.requireUnlocked(); err != nil {
+		return err
+	}
+	switch format {
+	case "csv":
+		return exportCSV(w, box.passwords)
+	case "json":
+		return json.NewEncoder(w).Encode(box.passwords)
+	case "1pux", "kdbx":
+		return fmt.Errorf("%s export is not implemented yet", format)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func exportCSV(w io.Writer, passwords []*Password) error {
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	if err := cw.Write([]string{"label", "account", "site", "note", "password"}); err != nil {
+		return err
+	}
+	for _, pw := range passwords {
+		if err := cw.Write([]string{pw.Label, pw.Account, pw.Site, pw.Note, pw.PlainPassword}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
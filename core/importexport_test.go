@@ -0,0 +1,71 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestImportJSONMapsExporterStyleKeys(t *testing.T) {
+	box := newTestBox(t)
+	if err := box.Init("master-password"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	input := strings.NewReader(`[
+		{"name": "Email", "url": "https://mail.example.com", "username": "alice", "password": "hunter2", "notes": "primary"}
+	]`)
+	result, err := box.Import(input, "json")
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result.Added != 1 {
+		t.Fatalf("Import result = %+v, want 1 added", result)
+	}
+
+	matches, err := box.Find("", "Email", "alice", "")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("Find after import = %d matches, want 1", len(matches))
+	}
+	pw := matches[0]
+	if pw.Site != "https://mail.example.com" || pw.PlainPassword != "hunter2" || pw.Note != "primary" {
+		t.Fatalf("imported entry = %+v, want site/password/note populated", pw)
+	}
+}
+
+func TestExportImportJSONRoundTrip(t *testing.T) {
+	box := newTestBox(t)
+	if err := box.Init("master-password"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if _, _, err := box.Add(&Password{Label: "email", Account: "alice", Site: "example.com", Note: "n", PlainPassword: "hunter2"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := box.Export(&buf, "json"); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	other := newTestBox(t)
+	if err := other.Init("master-password"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if _, err := other.Import(&buf, "json"); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	matches, err := other.Find("", "email", "alice", "")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("Find after round trip = %d matches, want 1", len(matches))
+	}
+	if got := matches[0]; got.Site != "example.com" || got.Note != "n" || got.PlainPassword != "hunter2" {
+		t.Fatalf("round-tripped entry = %+v, want site/note/password preserved", got)
+	}
+}
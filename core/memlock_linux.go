@@ -0,0 +1,22 @@
+//go:build linux
+
+package core
+
+import "syscall"
+
+// mlock pins b in physical memory so the kernel never writes it to swap,
+// where it could outlive the process's zeroing of it.
+func mlock(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return syscall.Mlock(b)
+}
+
+// munlock reverses mlock.
+func munlock(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return syscall.Munlock(b)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package core
+
+// mlock is a no-op on platforms where we don't have a syscall-level way to
+// keep b out of swap; the key is still zeroed on idle timeout, just not
+// pinned in RAM before that.
+func mlock(b []byte) error {
+	return nil
+}
+
+// munlock reverses mlock.
+func munlock(b []byte) error {
+	return nil
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// Password represents a single credential entry stored in a Box. The json
+// tags give it the same lowercase shape as main.passwordView, so
+// `export --format=json` round-trips with `import --format=json` and with
+// `list --format=json` instead of leaking Go's PascalCase field names.
+type Password struct {
+	Id       string `cli:"-" json:"id"`
+	Label    string `cli:"c,category" json:"label" usage:"password label/category"`
+	Account  string `cli:"u,account" json:"account" usage:"account name"`
+	Site     string `cli:"s,site" json:"site" usage:"site url"`
+	Note     string `cli:"note" json:"note" usage:"note"`
+
+	PlainPassword string `cli:"-" json:"password"`
+
+	CreatedAt time.Time `cli:"-" json:"createdAt"`
+	UpdatedAt time.Time `cli:"-" json:"updatedAt"`
+}
+
+// NewEmptyPassword creates a zero-value Password suitable as a cli.Command
+// Argv default, so flag parsing has somewhere to write into.
+func NewEmptyPassword() *Password {
+	return new(Password)
+}
+
+// CheckPassword validates a plaintext password before it is stored.
+func CheckPassword(pw string) error {
+	if len(pw) == 0 {
+		return fmt.Errorf("password is empty")
+	}
+	return nil
+}
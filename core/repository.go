@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Repository abstracts the encrypted box storage away from where it lives,
+// so a Box doesn't care whether its bytes come from a local file or
+// somewhere remote. Lock/Unlock guard the save of a new version of the box
+// with an advisory lease, so two machines editing the same remote box
+// don't race each other into a corrupt blob.
+type Repository interface {
+	Load() ([]byte, error)
+	Save(data []byte) error
+	Lock() error
+	Unlock() error
+}
+
+// NewRepository parses uri's scheme and returns the matching Repository
+// implementation: file:// (the default), git+ssh:// and sftp:// are fully
+// working; s3://, dropbox:// and gdrive:// are accepted but not
+// implemented yet and return an error from every Repository method.
+func NewRepository(uri string) (Repository, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repo URI %q: %v", uri, err)
+	}
+	switch u.Scheme {
+	case "", "file":
+		return NewFileRepository(u.Host + u.Path), nil
+	case "s3":
+		return newS3Repository(u), nil
+	case "dropbox":
+		return newDropboxRepository(u), nil
+	case "gdrive":
+		return newGDriveRepository(u), nil
+	case "sftp":
+		return newSFTPRepository(u), nil
+	case "git+ssh":
+		return newGitSSHRepository(u), nil
+	default:
+		return nil, fmt.Errorf("unknown repo scheme %q", u.Scheme)
+	}
+}
+
+// FileRepository stores the box as a single file on the local disk, guarded
+// by a sibling ".lock" lease file.
+type FileRepository struct {
+	filename string
+}
+
+// NewFileRepository creates a Repository backed by the local file named by
+// filename.
+func NewFileRepository(filename string) *FileRepository {
+	return &FileRepository{filename: filename}
+}
+
+// Load returns the file's contents, or nil if it doesn't exist yet: a
+// missing file is a brand new box, not an error, so callers (Box.Init in
+// particular) don't need to pre-create it before opening the repository.
+func (r *FileRepository) Load() ([]byte, error) {
+	data, err := ioutil.ReadFile(r.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (r *FileRepository) Save(data []byte) error {
+	return ioutil.WriteFile(r.filename, data, 0600)
+}
+
+func (r *FileRepository) lockFilename() string {
+	return r.filename + ".lock"
+}
+
+// Lock creates a lease file holding this process's pid, failing if a lease
+// already exists. It is not reentrant-safe across processes, which is the
+// point: a second `onepw` editing the same box (e.g. on another machine
+// sharing the file over a synced folder) must wait rather than race.
+func (r *FileRepository) Lock() error {
+	if _, err := os.Stat(r.lockFilename()); err == nil {
+		return fmt.Errorf("%s is locked, remove %s if this is stale", r.filename, r.lockFilename())
+	}
+	lease := fmt.Sprintf("%d\n%s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	return ioutil.WriteFile(r.lockFilename(), []byte(lease), 0600)
+}
+
+func (r *FileRepository) Unlock() error {
+	err := os.Remove(r.lockFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitSSHRepository stores the box as a single file inside a git repository
+// reached over ssh, shelling out to the system git binary rather than
+// pulling in an SSH/git client dependency. Every Load fetches and
+// hard-resets a local clone to the remote branch before reading the file,
+// and every Save
+// commits and pushes, so the git remote is the shared source of truth
+// across machines. Lock/Unlock commit and push a sibling ".lock" file
+// (mirroring FileRepository's lease file) so two machines editing the
+// same box race on the push instead of silently overwriting each other.
+type gitSSHRepository struct {
+	remote   string // git remote URL, e.g. ssh://git@host/path/repo.git
+	branch   string
+	file     string // path of the box file within the repository
+	cloneDir string // local working clone, one per remote+branch
+}
+
+func newGitSSHRepository(u *url.URL) Repository {
+	branch := "master"
+	file := "password.data"
+	if v := u.Query().Get("branch"); v != "" {
+		branch = v
+	}
+	if v := u.Query().Get("file"); v != "" {
+		file = v
+	}
+	remote := gitRemoteURL(u)
+	return &gitSSHRepository{
+		remote:   remote,
+		branch:   branch,
+		file:     file,
+		cloneDir: gitCloneDir(remote, branch),
+	}
+}
+
+// gitRemoteURL turns the git+ssh://user@host/path?query URI onepw was
+// configured with into the ssh:// remote URL git itself expects.
+func gitRemoteURL(u *url.URL) string {
+	userinfo := ""
+	if u.User != nil {
+		userinfo = u.User.String() + "@"
+	}
+	return "ssh://" + userinfo + u.Host + u.Path
+}
+
+// gitCloneDir picks a stable local clone directory for a remote+branch, so
+// repeated commands reuse the same working copy instead of re-cloning.
+func gitCloneDir(remote, branch string) string {
+	sum := sha1.Sum([]byte(remote + "#" + branch))
+	return filepath.Join(os.TempDir(), "onepw-git", fmt.Sprintf("%x", sum))
+}
+
+func (r *gitSSHRepository) lockFilename() string {
+	return r.file + ".lock"
+}
+
+// run executes git with args inside r.cloneDir.
+func (r *gitSSHRepository) run(args ...string) (string, error) {
+	return r.runEnv(nil, args...)
+}
+
+// runEnv is run with extra environment variables appended, used by commit
+// so it doesn't depend on the machine having git user.name/user.email
+// configured globally.
+func (r *gitSSHRepository) runEnv(env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.cloneDir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %v: %v: %s", args, err, out)
+	}
+	return string(out), nil
+}
+
+// sync ensures r.cloneDir holds an up-to-date clone of remote, cloning it
+// fresh the first time and hard-resetting it to origin/<branch> on every
+// later call, which drops any local commits that were never pushed. A
+// remote that has no commits on r.branch yet (a brand new box) is not an
+// error: sync falls back to a local repo that will create the branch on
+// the first Save/Lock push, the same way FileRepository starts from an
+// empty file.
+func (r *gitSSHRepository) sync() error {
+	if _, err := os.Stat(r.cloneDir); os.IsNotExist(err) {
+		return r.initClone()
+	}
+	if _, err := r.run("fetch", "origin", r.branch); err != nil {
+		if isMissingRemoteBranch(err) {
+			return nil
+		}
+		return err
+	}
+	_, err := r.run("reset", "--hard", "origin/"+r.branch)
+	return err
+}
+
+func (r *gitSSHRepository) initClone() error {
+	if err := os.MkdirAll(filepath.Dir(r.cloneDir), 0700); err != nil {
+		return err
+	}
+	cmd := exec.Command("git", "clone", "--branch", r.branch, r.remote, r.cloneDir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if !strings.Contains(string(out), "not found in upstream") {
+		return fmt.Errorf("git clone: %v: %s", err, out)
+	}
+	if err := os.MkdirAll(r.cloneDir, 0700); err != nil {
+		return err
+	}
+	if _, err := r.run("init", "--initial-branch="+r.branch); err != nil {
+		return err
+	}
+	_, err = r.run("remote", "add", "origin", r.remote)
+	return err
+}
+
+func isMissingRemoteBranch(err error) bool {
+	return strings.Contains(err.Error(), "couldn't find remote ref")
+}
+
+// commitAndPush stages every change under r.cloneDir and pushes it, so the
+// remote always reflects the latest Save/Lock/Unlock. The commit author is
+// fixed rather than relying on the machine's git user.name/user.email, and
+// a no-op commit (e.g. Unlock racing a concurrent Unlock) is skipped.
+var gitCommitEnv = []string{
+	"GIT_AUTHOR_NAME=onepw", "GIT_AUTHOR_EMAIL=onepw@localhost",
+	"GIT_COMMITTER_NAME=onepw", "GIT_COMMITTER_EMAIL=onepw@localhost",
+}
+
+func (r *gitSSHRepository) commitAndPush(message string) error {
+	if _, err := r.run("add", "-A"); err != nil {
+		return err
+	}
+	status, err := r.run("status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(status) != "" {
+		if _, err := r.runEnv(gitCommitEnv, "commit", "-m", message); err != nil {
+			return err
+		}
+	}
+	_, err = r.run("push", "origin", "HEAD:"+r.branch)
+	return err
+}
+
+func (r *gitSSHRepository) Load() ([]byte, error) {
+	if err := r.sync(); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(r.cloneDir, r.file))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (r *gitSSHRepository) Save(data []byte) error {
+	if err := r.sync(); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(r.cloneDir, r.file), data, 0600); err != nil {
+		return err
+	}
+	return r.commitAndPush("onepw: update password box")
+}
+
+// Lock commits and pushes a lease file recording this process, failing if
+// the remote already has one, so a second machine editing the same box
+// waits instead of racing it into a corrupt blob.
+func (r *gitSSHRepository) Lock() error {
+	if err := r.sync(); err != nil {
+		return err
+	}
+	lockPath := filepath.Join(r.cloneDir, r.lockFilename())
+	if _, err := os.Stat(lockPath); err == nil {
+		return fmt.Errorf("%s is locked, remove %s from %s if this is stale", r.file, r.lockFilename(), r.remote)
+	}
+	lease := fmt.Sprintf("%d\n%s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	if err := ioutil.WriteFile(lockPath, []byte(lease), 0600); err != nil {
+		return err
+	}
+	return r.commitAndPush("onepw: lock password box")
+}
+
+func (r *gitSSHRepository) Unlock() error {
+	lockPath := filepath.Join(r.cloneDir, r.lockFilename())
+	if err := os.Remove(lockPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return r.commitAndPush("onepw: unlock password box")
+}
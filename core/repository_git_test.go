@@ -0,0 +1,90 @@
+package core
+
+import (
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// newTestGitRepository points a gitSSHRepository at a local bare repo
+// instead of an ssh:// remote, so the clone/push/lock logic can be
+// exercised without a network.
+func newTestGitRepository(t *testing.T, bareDir string) *gitSSHRepository {
+	t.Helper()
+	return &gitSSHRepository{
+		remote:   bareDir,
+		branch:   "master",
+		file:     "password.data",
+		cloneDir: filepath.Join(t.TempDir(), "clone"),
+	}
+}
+
+func newBareRepo(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "bare.git")
+	if out, err := exec.Command("git", "init", "--bare", "--initial-branch=master", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare: %v: %s", err, out)
+	}
+	return dir
+}
+
+func TestGitSSHRepositorySaveLoadRoundTrip(t *testing.T) {
+	bareDir := newBareRepo(t)
+	repo := newTestGitRepository(t, bareDir)
+
+	data, err := repo.Load()
+	if err != nil {
+		t.Fatalf("Load on empty repo: %v", err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("Load on empty repo = %q, want empty", data)
+	}
+
+	want := []byte("encrypted box contents")
+	if err := repo.Save(want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// A second clone of the same remote should see what was pushed.
+	other := newTestGitRepository(t, bareDir)
+	got, err := other.Load()
+	if err != nil {
+		t.Fatalf("Load from second clone: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("Load from second clone = %q, want %q", got, want)
+	}
+}
+
+func TestGitSSHRepositoryLockUnlock(t *testing.T) {
+	bareDir := newBareRepo(t)
+	repo := newTestGitRepository(t, bareDir)
+
+	if err := repo.Lock(); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	other := newTestGitRepository(t, bareDir)
+	if err := other.Lock(); err == nil {
+		t.Fatal("Lock from a second clone should fail while the lease is held")
+	}
+
+	if err := repo.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+
+	if err := other.Lock(); err != nil {
+		t.Fatalf("Lock after Unlock: %v", err)
+	}
+}
+
+func TestGitRemoteURL(t *testing.T) {
+	u, err := url.Parse("git+ssh://git@example.com/onepw-box.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gitRemoteURL(u), "ssh://git@example.com/onepw-box.git"; got != want {
+		t.Fatalf("gitRemoteURL = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// remoteRepository is the shared skeleton for the backends below: the URI
+// they were built from, plus a not-yet-implemented error for every
+// Repository method. Each backend can be filled in independently without
+// touching core.NewRepository or the other backends.
+type remoteRepository struct {
+	kind string
+	uri  *url.URL
+}
+
+func (r *remoteRepository) errNotImplemented() error {
+	return fmt.Errorf("%s repo backend is not implemented yet (%s)", r.kind, r.uri)
+}
+
+func (r *remoteRepository) Load() ([]byte, error)  { return nil, r.errNotImplemented() }
+func (r *remoteRepository) Save(data []byte) error { return r.errNotImplemented() }
+func (r *remoteRepository) Lock() error            { return r.errNotImplemented() }
+func (r *remoteRepository) Unlock() error          { return r.errNotImplemented() }
+
+func newS3Repository(u *url.URL) Repository {
+	return &remoteRepository{kind: "s3", uri: u}
+}
+
+func newDropboxRepository(u *url.URL) Repository {
+	return &remoteRepository{kind: "dropbox", uri: u}
+}
+
+func newGDriveRepository(u *url.URL) Repository {
+	return &remoteRepository{kind: "gdrive", uri: u}
+}
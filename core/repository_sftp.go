@@ -0,0 +1,111 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// sftpRepository stores the box as a single file on a remote host reached
+// over ssh, shelling out to the system ssh binary rather than pulling in
+// an SSH client dependency (the same approach gitSSHRepository takes for
+// git+ssh://). Lock/Unlock write and remove a sibling ".lock" file on the
+// remote host so two machines editing the same box don't race each other
+// into a corrupt blob.
+type sftpRepository struct {
+	userhost string // e.g. "user@host"
+	port     string // ssh port, "" for the default
+	file     string // path of the box file on the remote host
+}
+
+func newSFTPRepository(u *url.URL) Repository {
+	userhost := u.Hostname()
+	port := u.Port()
+	if u.User != nil {
+		userhost = u.User.String() + "@" + userhost
+	}
+	file := strings.TrimPrefix(u.Path, "/")
+	if file == "" {
+		file = "password.data"
+	}
+	return &sftpRepository{userhost: userhost, port: port, file: file}
+}
+
+func (r *sftpRepository) lockFilename() string {
+	return r.file + ".lock"
+}
+
+// sshArgs prepends the -p flag (when a non-default port was given) and the
+// user@host target to a remote command's argv.
+func (r *sftpRepository) sshArgs(remoteCommand ...string) []string {
+	args := []string{}
+	if r.port != "" {
+		args = append(args, "-p", r.port)
+	}
+	args = append(args, r.userhost)
+	return append(args, strings.Join(remoteCommand, " "))
+}
+
+func quoteRemotePath(path string) string {
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
+
+// run executes a remote command over ssh and returns its stdout/stderr.
+func (r *sftpRepository) run(remoteCommand ...string) (stdout, stderr string, err error) {
+	cmd := exec.Command("ssh", r.sshArgs(remoteCommand...)...)
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err = cmd.Run()
+	return out.String(), errOut.String(), err
+}
+
+// runWrite executes a remote command over ssh with data piped to its
+// stdin, used to write a file on the remote host without an SFTP client.
+func (r *sftpRepository) runWrite(data []byte, remoteCommand ...string) error {
+	cmd := exec.Command("ssh", r.sshArgs(remoteCommand...)...)
+	cmd.Stdin = bytes.NewReader(data)
+	var errOut bytes.Buffer
+	cmd.Stderr = &errOut
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ssh %s: %v: %s", strings.Join(remoteCommand, " "), err, errOut.String())
+	}
+	return nil
+}
+
+func (r *sftpRepository) Load() ([]byte, error) {
+	out, errOut, err := r.run("cat", quoteRemotePath(r.file))
+	if err != nil {
+		if strings.Contains(errOut, "No such file or directory") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ssh cat %s: %v: %s", r.file, err, errOut)
+	}
+	return []byte(out), nil
+}
+
+func (r *sftpRepository) Save(data []byte) error {
+	return r.runWrite(data, "cat", ">", quoteRemotePath(r.file))
+}
+
+// Lock writes a lease file recording this process's pid on the remote
+// host, failing if one is already there, so a second machine editing the
+// same box waits instead of racing it into a corrupt blob.
+func (r *sftpRepository) Lock() error {
+	if _, _, err := r.run("test", "-e", quoteRemotePath(r.lockFilename())); err == nil {
+		return fmt.Errorf("%s is locked, remove %s on %s if this is stale", r.file, r.lockFilename(), r.userhost)
+	}
+	lease := []byte(fmt.Sprintf("%d\n", os.Getpid()))
+	return r.runWrite(lease, "cat", ">", quoteRemotePath(r.lockFilename()))
+}
+
+func (r *sftpRepository) Unlock() error {
+	_, errOut, err := r.run("rm", "-f", quoteRemotePath(r.lockFilename()))
+	if err != nil {
+		return fmt.Errorf("ssh rm %s: %v: %s", r.lockFilename(), err, errOut)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package main
+
+import "syscall"
+
+// detachedSysProcAttr has no portable equivalent of Setsid outside
+// linux/darwin; the child still outlives its parent, it just isn't moved
+// to its own session.
+func detachedSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build linux || darwin
+
+package main
+
+import "syscall"
+
+// detachedSysProcAttr starts the clipboard-clearing child in its own
+// session, so it keeps running (and keeps the terminal's signals from
+// reaching it) after the onepw invocation that spawned it has exited.
+func detachedSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/atotto/clipboard"
 	"github.com/labstack/gommon/color"
 	"github.com/mkideal/cli"
 	"github.com/mkideal/onepw/core"
@@ -12,6 +19,13 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 2 && os.Args[1] == clipboardClearerArg {
+		if err := runClipboardClearer(os.Args[2]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	cli.SetUsageStyle(cli.ManualStyle)
 	if err := cli.Root(root,
 		cli.Tree(help),
@@ -20,6 +34,17 @@ func main() {
 		cli.Tree(add),
 		cli.Tree(remove),
 		cli.Tree(list),
+		cli.Tree(gen),
+		cli.Tree(exportCmd),
+		cli.Tree(importCmd),
+		cli.Tree(get),
+		cli.Tree(agentCmd),
+		cli.Tree(key,
+			cli.Tree(keyList),
+			cli.Tree(keyAdd),
+			cli.Tree(keyRemove),
+			cli.Tree(keyPasswd),
+		),
 	).Run(os.Args[1:]); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -33,10 +58,14 @@ func main() {
 type Configure interface {
 	Filename() string
 	MasterPassword() string
+	RepoURI() string
+	AgentSocketPath() string
 }
 
 type Config struct {
-	Master string `cli:"master" usage:"master password" dft:"$PASSWORD_MASTER"`
+	Master      string `cli:"master" usage:"master password" dft:"$PASSWORD_MASTER"`
+	Repo        string `cli:"repo" usage:"storage backend URI: file://, git+ssh:// or sftp:// (s3://, dropbox://, gdrive:// are accepted but not implemented yet)" dft:"file://password.data"`
+	AgentSocket string `cli:"agent-socket" usage:"unix socket of a running onepw agent" dft:"$ONEPW_AGENT_SOCKET"`
 }
 
 func (cfg Config) Filename() string {
@@ -47,8 +76,52 @@ func (cfg Config) MasterPassword() string {
 	return cfg.Master
 }
 
+func (cfg Config) RepoURI() string {
+	if cfg.Repo != "" {
+		return cfg.Repo
+	}
+	return "file://" + cfg.Filename()
+}
+
+func (cfg Config) AgentSocketPath() string {
+	return cfg.AgentSocket
+}
+
 var box *core.Box
 
+// agentClient is set instead of box when a running onepw agent is reachable,
+// so the master password doesn't need to be typed again for this command.
+var agentClient *core.AgentClient
+
+// requireBox returns an error for commands that only know how to talk to a
+// local box: add, list and get go through the agent when one is reachable,
+// but the rest (key management, import/export, init) don't have an agent
+// op yet, so they need the box itself.
+func requireBox() error {
+	if box == nil {
+		return fmt.Errorf("this command doesn't support --agent-socket yet; drop it, or set --master so onepw can open the box directly")
+	}
+	return nil
+}
+
+// addPassword adds pw via the agent if one is connected, otherwise directly
+// against the local box.
+func addPassword(pw *core.Password) (string, bool, error) {
+	if agentClient != nil {
+		return agentClient.Add(pw)
+	}
+	return box.Add(pw)
+}
+
+// findPasswords looks up entries via the agent if one is connected,
+// otherwise directly against the local box.
+func findPasswords(id, label, account, query string) ([]*core.Password, error) {
+	if agentClient != nil {
+		return agentClient.Find(id, label, account, query)
+	}
+	return box.Find(id, label, account, query)
+}
+
 //--------------
 // root command
 //--------------
@@ -80,8 +153,8 @@ var root = &cli.Command{
 	#3. list all passwords
 	$> {{.onepw}} list
 
-	#optional
-	# upload cloud(e.g. dropbox or github or bitbucket ...)`, map[string]string{
+	#optional: store the box remotely instead of the local file
+	$> {{.onepw}} --repo=git+ssh://git@example.com/onepw-box.git list`, map[string]string{
 		"onepw":         color.Bold("onepw"),
 		"usage":         color.Bold("Usage"),
 		"basicworkflow": color.Bold("Basic workflow"),
@@ -102,17 +175,31 @@ var root = &cli.Command{
 	},
 
 	OnRootBefore: func(ctx *cli.Context) error {
-		if argv := ctx.Argv(); argv != nil {
-			if t, ok := argv.(Configure); ok {
-				repo := core.NewFileRepository(t.Filename())
-				box = core.NewBox(repo)
-				if t.MasterPassword() != "" {
-					return box.Init(t.MasterPassword())
-				}
+		argv := ctx.Argv()
+		if argv == nil {
+			return fmt.Errorf("box is nil")
+		}
+		t, ok := argv.(Configure)
+		if !ok {
+			return fmt.Errorf("box is nil")
+		}
+
+		if t.AgentSocketPath() != "" {
+			if client, err := core.DialAgent(t.AgentSocketPath()); err == nil {
+				agentClient = client
 				return nil
 			}
 		}
-		return fmt.Errorf("box is nil")
+
+		repo, err := core.NewRepository(t.RepoURI())
+		if err != nil {
+			return err
+		}
+		box = core.NewBox(repo)
+		if t.MasterPassword() != "" {
+			return box.Init(t.MasterPassword())
+		}
+		return nil
 	},
 
 	Fn: func(ctx *cli.Context) error {
@@ -152,37 +239,27 @@ type initT struct {
 	NewMaster string `cli:"new-master" usage:"new master password"`
 }
 
-func (argv *initT) Validate(ctx *cli.Context) error {
-	if argv.Filename() == "" {
-		return fmt.Errorf("FILE is empty")
-	}
-	return nil
-}
-
 var initCmd = &cli.Command{
 	Name: "init",
 	Desc: "init password box or modify master password",
 	Argv: func() interface{} { return new(initT) },
 
+	// No file pre-creation here: FileRepository.Load treats a missing
+	// file as a brand new, empty box, so Box.Init handles --repo naming
+	// a path that doesn't exist yet on its own, regardless of scheme.
 	OnBefore: func(ctx *cli.Context) error {
 		argv := ctx.Argv().(*initT)
 		if argv.Help {
 			ctx.WriteUsage()
 			return cli.ExitError
 		}
-		if _, err := os.Lstat(argv.Filename()); err != nil {
-			if os.IsNotExist(err) {
-				if file, err := os.Create(argv.Filename()); err != nil {
-					return err
-				} else {
-					file.Close()
-				}
-			}
-		}
 		return nil
 	},
 
 	Fn: func(ctx *cli.Context) error {
+		if err := requireBox(); err != nil {
+			return err
+		}
 		argv := ctx.Argv().(*initT)
 		if argv.NewMaster != "" {
 			return box.Init(argv.NewMaster)
@@ -198,11 +275,34 @@ type addT struct {
 	cli.Helper
 	Config
 	core.Password
-	Pw  string `pw:"pw,password" usage:"the password" prompt:"type the password"`
-	Cpw string `pw:"cpw,confirm-password" usage:"confirm password" prompt:"repeat the password"`
+	Pw            string `pw:"pw,password" usage:"the password" prompt:"type the password"`
+	Cpw           string `pw:"cpw,confirm-password" usage:"confirm password" prompt:"repeat the password"`
+	Generate      bool   `cli:"!g,generate" usage:"generate a random password instead of prompting for one"`
+	Copy          bool   `cli:"copy" usage:"copy the generated password to the clipboard instead of printing it"`
+	Length        int    `cli:"length" usage:"generated password length" dft:"20"`
+	NoNumbers     bool   `cli:"no-numbers" usage:"exclude digits from the generated password"`
+	NoSpecials    bool   `cli:"no-specials" usage:"exclude special characters from the generated password"`
+	NoUppercase   bool   `cli:"no-uppercase" usage:"exclude uppercase letters from the generated password"`
+	Pronounceable bool   `cli:"pronounceable" usage:"generate an easier-to-read, syllable-based password"`
+}
+
+func (argv *addT) policy() core.GeneratePolicy {
+	return core.GeneratePolicy{
+		Length:        argv.Length,
+		NoNumbers:     argv.NoNumbers,
+		NoSpecials:    argv.NoSpecials,
+		NoUppercase:   argv.NoUppercase,
+		Pronounceable: argv.Pronounceable,
+	}
 }
 
 func (argv *addT) Validate(ctx *cli.Context) error {
+	if argv.Copy && !argv.Generate {
+		return fmt.Errorf("--copy only works with --generate")
+	}
+	if argv.Generate {
+		return nil
+	}
 	if argv.Pw != argv.Cpw {
 		return fmt.Errorf("password mismatch")
 	}
@@ -230,8 +330,24 @@ var add = &cli.Command{
 
 	Fn: func(ctx *cli.Context) error {
 		argv := ctx.Argv().(*addT)
-		argv.Password.PlainPassword = argv.Pw
-		id, ok, err := box.Add(&argv.Password)
+		if argv.Generate {
+			pw, err := core.GeneratePassword(argv.policy())
+			if err != nil {
+				return err
+			}
+			if argv.Copy {
+				if err := clipboard.WriteAll(pw); err != nil {
+					return err
+				}
+				ctx.String("generated password copied to clipboard\n")
+			} else {
+				ctx.String("generated password: %s\n", pw)
+			}
+			argv.Password.PlainPassword = pw
+		} else {
+			argv.Password.PlainPassword = argv.Pw
+		}
+		id, ok, err := addPassword(&argv.Password)
 		if err != nil {
 			return err
 		}
@@ -244,6 +360,73 @@ var add = &cli.Command{
 	},
 }
 
+//-------------
+// gen command
+//-------------
+
+type genT struct {
+	cli.Helper
+	Length        int  `cli:"length" usage:"password length" dft:"20"`
+	NoNumbers     bool `cli:"no-numbers" usage:"exclude digits"`
+	NoSpecials    bool `cli:"no-specials" usage:"exclude special characters"`
+	NoUppercase   bool `cli:"no-uppercase" usage:"exclude uppercase letters"`
+	Pronounceable bool `cli:"pronounceable" usage:"generate an easier-to-read, syllable-based password"`
+	Count         int  `cli:"count" usage:"number of passwords to generate" dft:"1"`
+	Copy          bool `cli:"copy" usage:"copy the generated password to the clipboard instead of printing it"`
+}
+
+func (argv *genT) policy() core.GeneratePolicy {
+	return core.GeneratePolicy{
+		Length:        argv.Length,
+		NoNumbers:     argv.NoNumbers,
+		NoSpecials:    argv.NoSpecials,
+		NoUppercase:   argv.NoUppercase,
+		Pronounceable: argv.Pronounceable,
+	}
+}
+
+var gen = &cli.Command{
+	Name:   "gen",
+	Desc:   "generate one or more strong random passwords",
+	NoHook: true,
+	Argv:   func() interface{} { return new(genT) },
+
+	OnBefore: func(ctx *cli.Context) error {
+		argv := ctx.Argv().(*genT)
+		if argv.Help {
+			ctx.WriteUsage()
+			return cli.ExitError
+		}
+		return nil
+	},
+
+	Fn: func(ctx *cli.Context) error {
+		argv := ctx.Argv().(*genT)
+		count := argv.Count
+		if count <= 0 {
+			count = 1
+		}
+		if argv.Copy && count != 1 {
+			return fmt.Errorf("--copy only works with a single password, got --count=%d", count)
+		}
+		passwords := make([]string, count)
+		for i := range passwords {
+			pw, err := core.GeneratePassword(argv.policy())
+			if err != nil {
+				return err
+			}
+			passwords[i] = pw
+		}
+		if argv.Copy {
+			return clipboard.WriteAll(passwords[0])
+		}
+		for _, pw := range passwords {
+			ctx.String(pw + "\n")
+		}
+		return nil
+	},
+}
+
 //--------
 // remove
 //--------
@@ -272,6 +455,9 @@ var remove = &cli.Command{
 	},
 
 	Fn: func(ctx *cli.Context) error {
+		if err := requireBox(); err != nil {
+			return err
+		}
 		var (
 			argv = ctx.Argv().(*removeT)
 			ids  []string
@@ -302,11 +488,16 @@ var remove = &cli.Command{
 type listT struct {
 	cli.Helper
 	Config
+	Label        string `cli:"c,category" usage:"filter by label/category"`
+	Account      string `cli:"u,account" usage:"filter by account"`
+	Id           string `cli:"id" usage:"filter by password id"`
+	Format       string `cli:"format" usage:"output format: table, json or csv" dft:"table"`
+	ShowPassword bool   `cli:"show-password" usage:"print the real password instead of ********" dft:"false"`
 }
 
 var list = &cli.Command{
 	Name: "list",
-	Desc: "list all passwords",
+	Desc: "list passwords, optionally filtered by category, account, id or a free-text query",
 	Argv: func() interface{} { return new(listT) },
 
 	OnBefore: func(ctx *cli.Context) error {
@@ -319,7 +510,510 @@ var list = &cli.Command{
 	},
 
 	Fn: func(ctx *cli.Context) error {
-		//argv := ctx.Argv().(*listT)
-		return box.List(ctx)
+		argv := ctx.Argv().(*listT)
+		var query string
+		if args := ctx.Args(); len(args) > 0 {
+			query = args[0]
+		}
+		matches, err := findPasswords(argv.Id, argv.Label, argv.Account, query)
+		if err != nil {
+			return err
+		}
+		return printPasswords(ctx, matches, argv.Format, argv.ShowPassword)
+	},
+}
+
+// passwordView is the JSON/CSV/table projection of a core.Password: it
+// redacts the plaintext password unless the caller asked to see it.
+type passwordView struct {
+	Id       string `json:"id"`
+	Label    string `json:"label"`
+	Account  string `json:"account"`
+	Site     string `json:"site"`
+	Note     string `json:"note"`
+	Password string `json:"password"`
+}
+
+const redactedPassword = "********"
+
+func newPasswordView(pw *core.Password, showPassword bool) passwordView {
+	password := redactedPassword
+	if showPassword {
+		password = pw.PlainPassword
+	}
+	return passwordView{
+		Id:       pw.Id,
+		Label:    pw.Label,
+		Account:  pw.Account,
+		Site:     pw.Site,
+		Note:     pw.Note,
+		Password: password,
+	}
+}
+
+func printPasswords(ctx *cli.Context, matches []*core.Password, format string, showPassword bool) error {
+	views := make([]passwordView, len(matches))
+	for i, pw := range matches {
+		views[i] = newPasswordView(pw, showPassword)
+	}
+
+	switch format {
+	case "", "table":
+		for _, v := range views {
+			ctx.String("%s\t%s\t%s\t%s\t%s\n", v.Id, v.Label, v.Account, v.Site, v.Password)
+		}
+		return nil
+
+	case "json":
+		data, err := json.MarshalIndent(views, "", "  ")
+		if err != nil {
+			return err
+		}
+		ctx.String(string(data) + "\n")
+		return nil
+
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"id", "label", "account", "site", "note", "password"})
+		for _, v := range views {
+			w.Write([]string{v.Id, v.Label, v.Account, v.Site, v.Note, v.Password})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+		ctx.String(buf.String())
+		return nil
+
+	default:
+		return fmt.Errorf("unknown format %q, want table, json or csv", format)
+	}
+}
+
+//----------------
+// export command
+//----------------
+
+type exportT struct {
+	cli.Helper
+	Config
+	Format               string `cli:"format" usage:"export format: csv, json (1pux and kdbx are accepted but not implemented yet)" dft:"csv"`
+	IUnderstandPlaintext bool   `cli:"i-understand-plaintext" usage:"required to confirm a plaintext export format"`
+}
+
+// encryptedExportFormats lists the export formats allowed to skip the
+// --i-understand-plaintext confirmation because the container itself is
+// encrypted. kdbx belongs here once core.Box.Export actually produces a
+// real encrypted kdbx file; writing a correct one needs a KDBX4 reader to
+// validate against; that's flagged in core.Box.Export rather than
+// shipped unverified, so until then kdbx is plaintext-warning-or-bust
+// like every other format.
+var encryptedExportFormats = map[string]bool{}
+
+var exportCmd = &cli.Command{
+	Name: "export",
+	Desc: "export passwords for backup or migration to another password manager",
+	Argv: func() interface{} { return new(exportT) },
+
+	OnBefore: func(ctx *cli.Context) error {
+		argv := ctx.Argv().(*exportT)
+		if argv.Help {
+			ctx.WriteUsage()
+			return cli.ExitError
+		}
+		if !encryptedExportFormats[argv.Format] && !argv.IUnderstandPlaintext {
+			return fmt.Errorf("exporting as %s writes plaintext passwords to stdout; pass --i-understand-plaintext to confirm", argv.Format)
+		}
+		return nil
+	},
+
+	Fn: func(ctx *cli.Context) error {
+		if err := requireBox(); err != nil {
+			return err
+		}
+		argv := ctx.Argv().(*exportT)
+		return box.Export(os.Stdout, argv.Format)
+	},
+}
+
+//----------------
+// import command
+//----------------
+
+type importT struct {
+	cli.Helper
+	Config
+	Format string `cli:"format" usage:"import format: csv, json (1pux and kdbx are accepted but not implemented yet)" dft:"csv"`
+}
+
+var importCmd = &cli.Command{
+	Name: "import",
+	Desc: "import passwords exported from another password manager",
+	Argv: func() interface{} { return new(importT) },
+
+	OnBefore: func(ctx *cli.Context) error {
+		argv := ctx.Argv().(*importT)
+		if argv.Help {
+			ctx.WriteUsage()
+			return cli.ExitError
+		}
+		return nil
+	},
+
+	Fn: func(ctx *cli.Context) error {
+		if err := requireBox(); err != nil {
+			return err
+		}
+		args := ctx.Args()
+		if len(args) == 0 {
+			return fmt.Errorf("missing import file")
+		}
+		argv := ctx.Argv().(*importT)
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		result, err := box.Import(file, argv.Format)
+		if err != nil {
+			return err
+		}
+		ctx.String("added %d, updated %d, skipped %d\n", result.Added, result.Updated, result.Skipped)
+		return nil
+	},
+}
+
+//------------
+// get command
+//------------
+
+type getT struct {
+	cli.Helper
+	Config
+	Label            string `cli:"c,category" usage:"filter by label/category"`
+	Account          string `cli:"u,account" usage:"filter by account"`
+	Id               string `cli:"id" usage:"filter by password id"`
+	Clipboard        bool   `cli:"clipboard" usage:"copy the password to the clipboard instead of printing it"`
+	ClipboardTimeout int    `cli:"clipboard-timeout" usage:"seconds before the clipboard is cleared" dft:"30"`
+}
+
+var get = &cli.Command{
+	Name: "get",
+	Desc: "get a single password by id or free-text query",
+	Argv: func() interface{} { return new(getT) },
+
+	OnBefore: func(ctx *cli.Context) error {
+		argv := ctx.Argv().(*getT)
+		if argv.Help {
+			ctx.WriteUsage()
+			return cli.ExitError
+		}
+		return nil
+	},
+
+	Fn: func(ctx *cli.Context) error {
+		argv := ctx.Argv().(*getT)
+		var query string
+		if args := ctx.Args(); len(args) > 0 {
+			query = args[0]
+		}
+		matches, err := findPasswords(argv.Id, argv.Label, argv.Account, query)
+		if err != nil {
+			return err
+		}
+		switch len(matches) {
+		case 0:
+			return fmt.Errorf("no password matched")
+		case 1:
+			// fall through
+		default:
+			return fmt.Errorf("%d passwords matched, narrow it down with --id, --category or --account", len(matches))
+		}
+
+		pw := matches[0]
+		if !argv.Clipboard {
+			ctx.String(pw.PlainPassword + "\n")
+			return nil
+		}
+		return copyToClipboard(ctx, pw.PlainPassword, time.Duration(argv.ClipboardTimeout)*time.Second)
+	},
+}
+
+// copyToClipboard copies plaintext to the system clipboard and spawns a
+// detached clipboardClearerArg child to clear it again after timeout, so
+// the onepw invocation returns right away instead of blocking the shell
+// for the whole timeout.
+func copyToClipboard(ctx *cli.Context, plaintext string, timeout time.Duration) error {
+	if err := clipboard.WriteAll(plaintext); err != nil {
+		return err
+	}
+	ctx.String("copied to clipboard, clearing in %s\n", timeout)
+	return spawnClipboardClearer(plaintext, timeout)
+}
+
+// clipboardClearerArg, passed as os.Args[1], tells main to run as the
+// detached child spawned by spawnClipboardClearer instead of the normal
+// cli.Root command tree.
+const clipboardClearerArg = "__onepw-clear-clipboard"
+
+// spawnClipboardClearer re-execs the onepw binary as a detached child that
+// sleeps for timeout and then clears the clipboard, passing plaintext over
+// a pipe rather than an argument so it doesn't show up in a process
+// listing. The parent does not wait for it.
+func spawnClipboardClearer(plaintext string, timeout time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, clipboardClearerArg, timeout.String())
+	cmd.SysProcAttr = detachedSysProcAttr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if _, err := stdin.Write([]byte(plaintext)); err != nil {
+		stdin.Close()
+		return err
+	}
+	stdin.Close()
+	return cmd.Process.Release()
+}
+
+// runClipboardClearer is the entry point main dispatches to when invoked
+// with clipboardClearerArg: it reads the plaintext that was copied from
+// stdin, waits out timeout, then clears the clipboard, but only if it
+// still holds what was copied, so a password the user copied in the
+// meantime isn't wiped out from under them.
+func runClipboardClearer(timeoutArg string) error {
+	timeout, err := time.ParseDuration(timeoutArg)
+	if err != nil {
+		return err
+	}
+	plaintext, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	time.Sleep(timeout)
+	if current, err := clipboard.ReadAll(); err == nil && current == string(plaintext) {
+		return clipboard.WriteAll("")
+	}
+	return nil
+}
+
+//--------------
+// agent command
+//--------------
+
+type agentT struct {
+	cli.Helper
+	Config
+	IdleTimeout int `cli:"idle-timeout" usage:"seconds of inactivity before the agent zeroes its key and exits" dft:"900"`
+}
+
+func (argv *agentT) Validate(ctx *cli.Context) error {
+	if argv.AgentSocketPath() == "" {
+		return fmt.Errorf("--agent-socket (or $ONEPW_AGENT_SOCKET) is required")
+	}
+	return nil
+}
+
+var agentCmd = &cli.Command{
+	Name: "agent",
+	Desc: "run a long-lived process that holds the unlocked box so other commands don't have to reprompt for the master password",
+	Argv: func() interface{} { return new(agentT) },
+
+	OnBefore: func(ctx *cli.Context) error {
+		argv := ctx.Argv().(*agentT)
+		if argv.Help {
+			ctx.WriteUsage()
+			return cli.ExitError
+		}
+		return nil
+	},
+
+	Fn: func(ctx *cli.Context) error {
+		argv := ctx.Argv().(*agentT)
+		if agentClient != nil {
+			return fmt.Errorf("an agent is already listening on %s", argv.AgentSocketPath())
+		}
+		if box == nil || !box.Unlocked() {
+			return fmt.Errorf("--master is required to start the agent")
+		}
+		idleTimeout := time.Duration(argv.IdleTimeout) * time.Second
+		ctx.String("onepw agent listening on %s\n", argv.AgentSocketPath())
+		return core.NewAgent(box, idleTimeout).ListenAndServe(argv.AgentSocketPath())
+	},
+}
+
+//-----------------------
+// key management command
+//-----------------------
+
+type keyT struct {
+	cli.Helper
+	Config
+}
+
+var key = &cli.Command{
+	Name:        "key",
+	Desc:        "manage the master keys that unlock this box",
+	Argv:        func() interface{} { return new(keyT) },
+	CanSubRoute: true,
+
+	OnBefore: func(ctx *cli.Context) error {
+		argv := ctx.Argv().(*keyT)
+		if argv.Help {
+			ctx.WriteUsage()
+			return cli.ExitError
+		}
+		return nil
+	},
+
+	Fn: func(ctx *cli.Context) error {
+		ctx.WriteUsage()
+		return nil
+	},
+}
+
+//-----------
+// key list
+//-----------
+
+type keyListT struct {
+	cli.Helper
+	Config
+}
+
+var keyList = &cli.Command{
+	Name: "list",
+	Desc: "list the keys that can unlock this box",
+	Argv: func() interface{} { return new(keyListT) },
+
+	Fn: func(ctx *cli.Context) error {
+		if err := requireBox(); err != nil {
+			return err
+		}
+		for _, k := range box.ListKeys() {
+			who := k.Username
+			if k.Hostname != "" {
+				who = strings.TrimSpace(who + "@" + k.Hostname)
+			}
+			ctx.String("%s\t%s\t%s\n", k.Id, who, k.Created.Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+//----------
+// key add
+//----------
+
+type keyAddT struct {
+	cli.Helper
+	Config
+	User string `cli:"user" usage:"username this key belongs to"`
+	Host string `cli:"host" usage:"hostname this key belongs to"`
+	Pw   string `pw:"pw,password" usage:"the new key's password" prompt:"type the password"`
+	Cpw  string `pw:"cpw,confirm-password" usage:"confirm password" prompt:"repeat the password"`
+}
+
+func (argv *keyAddT) Validate(ctx *cli.Context) error {
+	if argv.Pw != argv.Cpw {
+		return fmt.Errorf("password mismatch")
+	}
+	return core.CheckPassword(argv.Pw)
+}
+
+var keyAdd = &cli.Command{
+	Name: "add",
+	Desc: "add a new key that can unlock this box with its own password",
+	Argv: func() interface{} { return new(keyAddT) },
+
+	Fn: func(ctx *cli.Context) error {
+		if err := requireBox(); err != nil {
+			return err
+		}
+		argv := ctx.Argv().(*keyAddT)
+		id, err := box.AddKey(argv.User, argv.Host, argv.Pw)
+		if err != nil {
+			return err
+		}
+		ctx.String("key %s added\n", id)
+		return nil
+	},
+}
+
+//-------------
+// key remove
+//-------------
+
+type keyRemoveT struct {
+	cli.Helper
+	Config
+}
+
+var keyRemove = &cli.Command{
+	Name: "remove",
+	Desc: "revoke a key by id",
+	Argv: func() interface{} { return new(keyRemoveT) },
+
+	Fn: func(ctx *cli.Context) error {
+		if err := requireBox(); err != nil {
+			return err
+		}
+		args := ctx.Args()
+		if len(args) == 0 {
+			return fmt.Errorf("missing key id")
+		}
+		if err := box.RemoveKey(args[0]); err != nil {
+			return err
+		}
+		ctx.String("key %s removed\n", args[0])
+		return nil
+	},
+}
+
+//-------------
+// key passwd
+//-------------
+
+type keyPasswdT struct {
+	cli.Helper
+	Config
+	Pw  string `pw:"pw,password" usage:"the new password" prompt:"type the new password"`
+	Cpw string `pw:"cpw,confirm-password" usage:"confirm password" prompt:"repeat the new password"`
+}
+
+func (argv *keyPasswdT) Validate(ctx *cli.Context) error {
+	if argv.Pw != argv.Cpw {
+		return fmt.Errorf("password mismatch")
+	}
+	return core.CheckPassword(argv.Pw)
+}
+
+var keyPasswd = &cli.Command{
+	Name: "passwd",
+	Desc: "rotate a single key's password without touching the others",
+	Argv: func() interface{} { return new(keyPasswdT) },
+
+	Fn: func(ctx *cli.Context) error {
+		if err := requireBox(); err != nil {
+			return err
+		}
+		args := ctx.Args()
+		if len(args) == 0 {
+			return fmt.Errorf("missing key id")
+		}
+		argv := ctx.Argv().(*keyPasswdT)
+		if err := box.RotateKey(args[0], argv.Pw); err != nil {
+			return err
+		}
+		ctx.String("key %s rotated\n", args[0])
+		return nil
 	},
 }